@@ -9,19 +9,26 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alecthomas/kong"
+	"github.com/itchyny/timefmt-go"
 	"github.com/nleeper/goment"
+	"golang.org/x/term"
 )
 
 // TODO: Not use CLI as a singleton. Instead of instantiate it in the main
 // method to make all the functions testable.
 var CLI struct {
-	Milliseconds bool `help:"Search for UNIX timestamps in millisecond resolution. Without this, second resolution is expected. Currently, decimal points for UNIX timestamps isn't supported."`
+	Milliseconds bool `help:"Search for UNIX timestamps in millisecond resolution." xor:"precision"`
+	Microseconds bool `help:"Search for UNIX timestamps in microsecond resolution." xor:"precision"`
+	Nanoseconds  bool `help:"Search for UNIX timestamps in nanosecond resolution." xor:"precision"`
 
-	From time.Time `help:"The earliest UNIX timestamp we match. RFC3339." group:"Exact time span for UNIX timestamp matching. Defaults to --relative-interval if not defined. Flags:"`
-	To   time.Time `help:"The latest UNIX timestamp we match. Uses RFC3339." group:"Exact time span for UNIX timestamp matching. Defaults to --relative-interval if not defined. Flags:"`
+	AutoPrecision bool `name:"auto-precision" help:"Automatically detect the precision (seconds, milliseconds, microseconds or nanoseconds) of each matched integer by checking which interpretation falls inside the configured time range. Useful for input that mixes timestamp precisions." xor:"precision"`
+
+	From flexTime `help:"The earliest timestamp we match. Accepts a Go duration relative to now (e.g. -2h), 'now', RFC3339(Nano), 2006-01-02T15:04:05, or 2006-01-02." group:"Exact time span for UNIX timestamp matching. Defaults to --relative-interval if not defined. Flags:"`
+	To   flexTime `help:"The latest timestamp we match. Accepts the same formats as --from." group:"Exact time span for UNIX timestamp matching. Defaults to --relative-interval if not defined. Flags:"`
 
 	RelTimeInterval time.Duration `name:"relative-interval" help:"The time interval +/- from current time for which UNIX timestamps are matched. Defaults to 10 years." default:"87600h"`
 
@@ -29,8 +36,16 @@ var CLI struct {
 
 	OutputMode string `name:"output-mode" help:"Whether the time should be absolute, relative, or both." enum:"absolute,relative,absolute+relative" default:"absolute"`
 
-	PredefAbsoluteFormat string `name:"predefined-format" short:"p" help:"Predefined time format to replace UNIX timestamps with." enum:"RFC3339,RFC3339Nano,custom" default:"RFC3339"`
-	Format               string `help:"Time format to replace UNIX timestamps with. Uses the same format as https://golang.org/pkg/time/#Parse with the exception that 'REL' gets replaced with a relative time." default:"2006-01-02T15:04:05Z07:00"`
+	PredefAbsoluteFormat string `name:"predefined-format" short:"p" help:"Predefined time format to replace UNIX timestamps with. 'strftime' interprets --format as POSIX strftime specifiers (see http://man7.org/linux/man-pages/man3/strftime.3.html) instead of a Go reference layout." enum:"RFC3339,RFC3339Nano,custom,strftime" default:"RFC3339"`
+	Format               string `help:"Time format to replace UNIX timestamps with. Uses the same format as https://golang.org/pkg/time/#Parse (or strftime specifiers when --predefined-format=strftime), with the exception that 'REL' gets replaced with a relative time." default:"2006-01-02T15:04:05Z07:00"`
+
+	Fractional bool `help:"Also match a fractional part after a decimal point (e.g. 1700000000.123456) and preserve sub-second precision in the output. Only applies to second-resolution matching." xor:"precision"`
+
+	Reverse     bool     `help:"Reverse mode: find human-readable timestamps in the input and replace them with UNIX timestamps, instead of the other way around."`
+	ParseFormat []string `name:"parse-format" help:"In --reverse mode, additional layout(s) (Go reference-time or strftime) to recognize, on top of RFC3339 and RFC3339Nano."`
+
+	Color      string `help:"Colorize matched timestamps by age. 'auto' colorizes only when stdout is a terminal." enum:"auto,always,never" default:"auto"`
+	AgeBuckets string `name:"age-buckets" help:"Comma-separated age thresholds (Go durations), from newest to oldest, used to bucket colorized output." default:"1h,24h,168h,720h"`
 
 	Unbuffered bool `help:"Don't buffer output. This will slow down the application."`
 
@@ -39,26 +54,52 @@ var CLI struct {
 
 var number *regexp.Regexp
 
-func init() {
-	number = regexp.MustCompile(fmt.Sprintf(`0*(?P<number>[1-9][0-9]{0,%d})`, len(strconv.FormatInt(math.MaxInt64, 10))))
+// compileNumberRegex builds the `number` regexp. It must run after kong has
+// parsed CLI.Fractional: the trailing (\.[0-9]+)? group is only included
+// when --fractional is set, so that otherwise a bare integer followed by a
+// decimal point (e.g. end-of-sentence punctuation, or a version number) has
+// its fractional part left untouched in the output instead of being
+// silently swallowed and dropped.
+func compileNumberRegex() {
+	pattern := fmt.Sprintf(`0*(?P<number>[1-9][0-9]{0,%d})`, len(strconv.FormatInt(math.MaxInt64, 10)))
+	if CLI.Fractional {
+		pattern += `(\.[0-9]+)?`
+	}
+	number = regexp.MustCompile(pattern)
 	number.Longest()
 }
 
 func main() {
 	kong.Parse(&CLI, kong.Description("A command line utility that will replace UNIX timestamps with human interpretable timestamps."))
+	compileNumberRegex()
 
-	unixRange := newUnixRange(newTimeRange())
-	timeConverter := newTimeConverter()
-	formatter := newTimeFormatter()
+	tRange := newTimeRange()
 
 	scanner := newScanner()
 	output := newBufferedWriter(os.Stdout)
 	defer output.Flush()
 
+	if CLI.Reverse {
+		runReverse(tRange, scanner, output)
+	} else {
+		run(tRange, scanner, output)
+	}
+
+	if err := scanner.Err(); err != nil {
+		fatalLn("Invalid input:", err)
+	}
+}
+
+// run implements the default mode: UNIX timestamps in the input are
+// replaced with human interpretable timestamps.
+func run(tRange timeRange, scanner *bufio.Scanner, output *bufio.Writer) {
+	unixRanges := newUnixRanges(tRange)
+	formatter := newTimeFormatter()
+
 	splitter := &numberSplitter{}
 	scanner.Split(splitter.Split)
 
-	matcher := newMatcher(unixRange)
+	matcher := newMatcher(unixRanges, newTimeConverter())
 	for scanner.Scan() {
 		data := scanner.Bytes()
 
@@ -69,21 +110,54 @@ func main() {
 			}
 			continue
 		}
-		unix, match := matcher.Match(data[numberLoc[2]:numberLoc[3]])
+		number := data[numberLoc[2]:numberLoc[3]]
+		var frac []byte
+		if len(numberLoc) > 4 && numberLoc[4] != -1 {
+			frac = data[numberLoc[4]:numberLoc[5]]
+		}
+		tstamp, match := matcher.Match(number, frac)
 		if !match {
 			if _, err := output.Write(data); err != nil {
 				fatalLn("Unable to write output:", err)
 			}
 			continue
 		}
-		tstamp := timeConverter(unix)
 		toPrint := formatter.Format(tstamp)
 		if _, err := output.Write([]byte(toPrint)); err != nil {
 			fatalLn("Unable to write output:", err)
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		fatalLn("Invalid input:", err)
+}
+
+// runReverse implements --reverse: human-readable timestamps in the input
+// are replaced with UNIX timestamps.
+func runReverse(tRange timeRange, scanner *bufio.Scanner, output *bufio.Writer) {
+	layouts := append([]string{time.RFC3339, time.RFC3339Nano}, CLI.ParseFormat...)
+	splitter := newTimestampSplitter(layouts)
+	scanner.Split(splitter.Split)
+
+	p := precisionFromCLI()
+	for scanner.Scan() {
+		data := scanner.Bytes()
+
+		tsLoc := splitter.TsLoc
+		if tsLoc == nil {
+			if _, err := output.Write(data); err != nil {
+				fatalLn("Unable to write output:", err)
+			}
+			continue
+		}
+		t, match := splitter.Parse(data[tsLoc[0]:tsLoc[1]])
+		if !match || !tRange.Contains(t) {
+			if _, err := output.Write(data); err != nil {
+				fatalLn("Unable to write output:", err)
+			}
+			continue
+		}
+		unix := t.UnixNano() / p.nanosPerUnit()
+		if _, err := output.Write([]byte(strconv.FormatInt(unix, 10))); err != nil {
+			fatalLn("Unable to write output:", err)
+		}
 	}
 }
 
@@ -107,12 +181,16 @@ type timeRange struct {
 	Upper time.Time
 }
 
+func (r timeRange) Contains(t time.Time) bool {
+	return !t.Before(r.Lower) && !t.After(r.Upper)
+}
+
 func newTimeRange() timeRange {
 	useAbsolute := !CLI.From.IsZero() || !CLI.To.IsZero()
 	if useAbsolute {
 		return timeRange{
-			Lower: CLI.From,
-			Upper: CLI.To,
+			Lower: CLI.From.Time,
+			Upper: CLI.To.Time,
 		}
 	}
 
@@ -123,45 +201,130 @@ func newTimeRange() timeRange {
 	}
 }
 
-func newUnixRange(r timeRange) unixRange {
-	if CLI.Milliseconds {
-		return unixRange{
-			Lower: r.Lower.UnixNano() / nanosPerMs,
-			Upper: r.Upper.UnixNano() / nanosPerMs,
+// flexTime is a time.Time that can also be decoded from kong flags written
+// the way Docker/Podman's --since/--until accept them: a Go duration
+// relative to now, the literal "now", or one of a handful of absolute
+// layouts from most to least precise.
+type flexTime struct {
+	time.Time
+}
+
+// flexTimeLayouts are tried in order after duration and "now" parsing have
+// failed. The last two are interpreted in the local timezone, mirroring how
+// a human would type a bare date or date-time on the command line.
+var flexTimeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, which kong uses to
+// decode flag values.
+func (f *flexTime) UnmarshalText(text []byte) error {
+	s := string(text)
+	if s == "now" {
+		*f = flexTime{time.Now()}
+		return nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		*f = flexTime{time.Now().Add(d)}
+		return nil
+	}
+	for _, layout := range flexTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			*f = flexTime{t}
+			return nil
 		}
 	}
-	return unixRange{
-		Lower: r.Lower.Unix(),
-		Upper: r.Upper.Unix(),
+	return fmt.Errorf("unable to parse %q as a duration, \"now\", or one of %v", s, flexTimeLayouts)
+}
+
+// precision identifies the unit a matched UNIX integer is expressed in.
+type precision int
+
+const (
+	precisionSeconds precision = iota
+	precisionMilliseconds
+	precisionMicroseconds
+	precisionNanoseconds
+)
+
+const nanosPerSecond = int64(time.Second / time.Nanosecond)
+const nanosPerMs = int64(time.Millisecond / time.Nanosecond)
+const nanosPerUs = int64(time.Microsecond / time.Nanosecond)
+
+// nanosPerUnit returns how many nanoseconds a single unit of p represents.
+func (p precision) nanosPerUnit() int64 {
+	switch p {
+	case precisionMilliseconds:
+		return nanosPerMs
+	case precisionMicroseconds:
+		return nanosPerUs
+	case precisionNanoseconds:
+		return 1
+	default:
+		return nanosPerSecond
 	}
 }
 
-func newTimeConverter() func(unix int64) time.Time {
-	if CLI.Milliseconds {
-		return millisecondConverter
+// precisionFromCLI returns the single precision requested on the command
+// line, defaulting to seconds. It is meaningless when CLI.AutoPrecision is
+// set, since then every precision is tried.
+func precisionFromCLI() precision {
+	switch {
+	case CLI.Nanoseconds:
+		return precisionNanoseconds
+	case CLI.Microseconds:
+		return precisionMicroseconds
+	case CLI.Milliseconds:
+		return precisionMilliseconds
+	default:
+		return precisionSeconds
 	}
-	return secondConverter
 }
 
-const nanosPerMs = int64(time.Millisecond / time.Nanosecond)
+// unixRanges is the set of unixRange windows - one per precision - that
+// matched integers are checked against. It has more than one entry only
+// when CLI.AutoPrecision is set.
+type unixRanges []unixRange
 
-func millisecondConverter(unix int64) time.Time {
-	seconds, nanos := unix/1000, nanosPerMs*(unix%1000)
-	return time.Unix(seconds, nanos)
+func newUnixRanges(r timeRange) unixRanges {
+	if CLI.AutoPrecision {
+		return unixRanges{
+			newUnixRange(r, precisionSeconds),
+			newUnixRange(r, precisionMilliseconds),
+			newUnixRange(r, precisionMicroseconds),
+			newUnixRange(r, precisionNanoseconds),
+		}
+	}
+	return unixRanges{newUnixRange(r, precisionFromCLI())}
 }
 
-func secondConverter(unix int64) time.Time {
-	return time.Unix(unix, 0)
+func newUnixRange(r timeRange, p precision) unixRange {
+	divisor := p.nanosPerUnit()
+	return unixRange{
+		Precision: p,
+		Lower:     r.Lower.UnixNano() / divisor,
+		Upper:     r.Upper.UnixNano() / divisor,
+	}
+}
+
+func newTimeConverter() func(unix int64, p precision) time.Time {
+	return func(unix int64, p precision) time.Time {
+		return time.Unix(0, unix*p.nanosPerUnit())
+	}
 }
 
 func newTimeFormatter() timeFormatter {
+	var f timeFormatter
 	switch CLI.OutputMode {
 	case "absolute":
-		return newAbsoluteFormatter()
+		f = newAbsoluteFormatter()
 	case "relative":
-		return relativeFormatter{}
+		f = relativeFormatter{}
 	case "absolute+relative":
-		return combinedFormatter{
+		f = combinedFormatter{
 			Base:        newAbsoluteFormatter(),
 			Parenthesis: relativeFormatter{},
 		}
@@ -169,6 +332,21 @@ func newTimeFormatter() timeFormatter {
 		panic(fmt.Sprintf("unexpected mode: %s", CLI.OutputMode))
 	}
 
+	if useColor() {
+		return colorizingFormatter{Inner: f, Buckets: newAgeBuckets()}
+	}
+	return f
+}
+
+func useColor() bool {
+	switch CLI.Color {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return term.IsTerminal(int(os.Stdout.Fd()))
+	}
 }
 
 type timeFormatter interface {
@@ -179,25 +357,59 @@ func newAbsoluteFormatter() absoluteFormatter {
 	return absoluteFormatter{timeFormat()}
 }
 
-func timeFormat() string {
+// formatKind distinguishes the two layout dialects an absoluteFormatter can
+// render with.
+type formatKind int
+
+const (
+	formatKindGoLayout formatKind = iota
+	formatKindStrftime
+)
+
+type timeFormatSpec struct {
+	Kind   formatKind
+	Layout string
+}
+
+func timeFormat() timeFormatSpec {
 	switch CLI.PredefAbsoluteFormat {
 	case "RFC3339":
-		return time.RFC3339
+		return timeFormatSpec{formatKindGoLayout, time.RFC3339}
 	case "RFC3339Nano":
-		return time.RFC3339Nano
+		return timeFormatSpec{formatKindGoLayout, time.RFC3339Nano}
 	case "custom":
-		return CLI.Format
+		return timeFormatSpec{formatKindGoLayout, CLI.Format}
+	case "strftime":
+		return timeFormatSpec{formatKindStrftime, CLI.Format}
 	default:
-		panic(fmt.Sprintf("unexpected predefined format: %s", CLI.OutputMode))
+		panic(fmt.Sprintf("unexpected predefined format: %s", CLI.PredefAbsoluteFormat))
 	}
 }
 
 type absoluteFormatter struct {
-	Layout string
+	Spec timeFormatSpec
 }
 
 func (g absoluteFormatter) Format(t time.Time) string {
-	return t.Format(g.Layout)
+	var formatted string
+	switch g.Spec.Kind {
+	case formatKindStrftime:
+		formatted = timefmt.Format(t, g.Spec.Layout)
+	default:
+		formatted = t.Format(g.Spec.Layout)
+	}
+	return substituteRel(formatted, t)
+}
+
+// substituteRel implements the 'REL' placeholder documented on --format: a
+// pass run over the output of the actual layout formatting, since neither Go's
+// reference layout nor strftime specifiers have a directive of their own
+// for "time relative to now".
+func substituteRel(s string, t time.Time) string {
+	if !strings.Contains(s, "REL") {
+		return s
+	}
+	return strings.ReplaceAll(s, "REL", relativeFormatter{}.Format(t))
 }
 
 type relativeFormatter struct {
@@ -220,6 +432,70 @@ func (g combinedFormatter) Format(t time.Time) string {
 	return fmt.Sprintf("%s (%s)", g.Base.Format(t), g.Parenthesis.Format(t))
 }
 
+const (
+	ansiReset   = "\x1b[0m"
+	ansiDim     = "\x1b[2m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan    = "\x1b[36m"
+	ansiRed     = "\x1b[31m"
+)
+
+// ageBucket is the oldest age, and the ANSI color code to use, for one step
+// of the --age-buckets ladder.
+type ageBucket struct {
+	Max  time.Duration
+	Code string
+}
+
+// ageBucketColors are assigned to the --age-buckets thresholds in order,
+// newest first; anything older than the last bucket gets ansiRed, and
+// anything in the future gets ansiDim. An --age-buckets list with more
+// thresholds than there are entries here is rejected by newAgeBuckets.
+var ageBucketColors = []string{ansiGreen, ansiYellow, ansiMagenta, ansiCyan}
+
+func newAgeBuckets() []ageBucket {
+	raw := strings.Split(CLI.AgeBuckets, ",")
+	if len(raw) > len(ageBucketColors) {
+		fatalLn(fmt.Sprintf("--age-buckets supports at most %d thresholds, got %d", len(ageBucketColors), len(raw)))
+	}
+	buckets := make([]ageBucket, len(raw))
+	for i, r := range raw {
+		d, err := time.ParseDuration(strings.TrimSpace(r))
+		if err != nil {
+			fatalLn("Invalid --age-buckets entry:", r, err)
+		}
+		buckets[i] = ageBucket{Max: d, Code: ageBucketColors[i]}
+	}
+	return buckets
+}
+
+// colorizingFormatter wraps another timeFormatter, prefixing its output with
+// an ANSI color code chosen by how old t is relative to time.Now(), per
+// Buckets, and appending a reset code. Whether it is used at all (e.g. only
+// on a TTY) is decided by useColor.
+type colorizingFormatter struct {
+	Inner   timeFormatter
+	Buckets []ageBucket
+}
+
+func (g colorizingFormatter) Format(t time.Time) string {
+	return g.ansiCode(time.Since(t)) + g.Inner.Format(t) + ansiReset
+}
+
+func (g colorizingFormatter) ansiCode(age time.Duration) string {
+	if age < 0 {
+		return ansiDim
+	}
+	for _, b := range g.Buckets {
+		if age < b.Max {
+			return b.Code
+		}
+	}
+	return ansiRed
+}
+
 func fatalLn(a ...interface{}) {
 	fmt.Println(a...)
 	os.Exit(1)
@@ -274,10 +550,12 @@ func (n *numberSplitter) Split(data []byte, atEOF bool) (advance int, token []by
 		toReturn := data[0:loc[0]]
 
 		toSubtract := loc[0]
+		for i := 1; i < len(loc); i++ {
+			if loc[i] >= 0 {
+				loc[i] -= toSubtract
+			}
+		}
 		loc[0] = 0
-		loc[1] -= toSubtract
-		loc[2] -= toSubtract
-		loc[3] -= toSubtract
 		n.nextLoc = loc
 
 		return len(toReturn), toReturn, nil
@@ -287,9 +565,164 @@ func (n *numberSplitter) Split(data []byte, atEOF bool) (advance int, token []by
 	return loc[1] - loc[0], data[loc[0]:loc[1]], nil
 }
 
+// timestampSplitter is numberSplitter's sibling for --reverse: instead of
+// bare integers, it looks for substrings shaped like one of a configured set
+// of timestamp layouts.
+type timestampSplitter struct {
+	layouts []string
+	pattern *regexp.Regexp
+
+	// TsLoc contains the [start, end) location of the matched timestamp
+	// candidate in the previously returned token, or nil if none was found.
+	TsLoc        []int
+	nextLoc      []int
+	nextNeedMore bool
+}
+
+func newTimestampSplitter(layouts []string) *timestampSplitter {
+	patterns := make([]string, 0, len(layouts))
+	kept := make([]string, 0, len(layouts))
+	for _, layout := range layouts {
+		// An empty layout would turn into an empty regexp branch, which
+		// matches the empty string at every position and makes the
+		// bufio.Scanner panic on "empty tokens without progressing".
+		if layout == "" {
+			continue
+		}
+		if pattern := layoutToPattern(layout); pattern != "" {
+			patterns = append(patterns, pattern)
+			kept = append(kept, layout)
+		}
+	}
+	if len(patterns) == 0 {
+		fatalLn("No usable timestamp layouts configured for --reverse (check --parse-format for empty entries)")
+	}
+	return &timestampSplitter{
+		layouts: kept,
+		pattern: regexp.MustCompile(strings.Join(patterns, "|")),
+	}
+}
+
+// Split implements bufio.SplitFunc. It otherwise follows numberSplitter.Split
+// closely, but since none of the layouts need the leading-zero stripping
+// that justified numberSplitter's use of named submatches, plain FindIndex
+// is enough here.
+func (s *timestampSplitter) Split(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if s.nextNeedMore {
+		s.nextNeedMore = false
+		return 0, nil, nil
+	}
+	if s.nextLoc != nil {
+		loc := s.nextLoc
+		s.TsLoc = loc
+		s.nextLoc = nil
+		return loc[1] - loc[0], data[loc[0]:loc[1]], nil
+	}
+
+	s.TsLoc = nil
+	loc := s.pattern.FindIndex(data)
+	if loc == nil {
+		if atEOF {
+			return len(data), data, bufio.ErrFinalToken
+		}
+		return len(data), data, nil
+	}
+	if loc[0] > 0 {
+		if loc[1] == len(data) {
+			if atEOF {
+				return len(data), data, bufio.ErrFinalToken
+			}
+			s.nextNeedMore = true
+			return loc[0], data[0:loc[0]], nil
+		}
+
+		toReturn := data[0:loc[0]]
+		toSubtract := loc[0]
+		s.nextLoc = []int{loc[0] - toSubtract, loc[1] - toSubtract}
+		return len(toReturn), toReturn, nil
+	}
+
+	s.TsLoc = loc
+	return loc[1] - loc[0], data[loc[0]:loc[1]], nil
+}
+
+// Parse tries b against every configured layout, both as a Go reference
+// layout and as a strftime pattern, and returns the first one that parses.
+func (s *timestampSplitter) Parse(b []byte) (time.Time, bool) {
+	str := string(b)
+	for _, layout := range s.layouts {
+		if t, err := time.Parse(layout, str); err == nil {
+			return t, true
+		}
+		if t, err := timefmt.Parse(str, layout); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// layoutToPattern derives a best-effort regexp for locating candidate
+// timestamp substrings that might be parseable with layout. It understands
+// the numeric Go reference-time tokens and the strftime directives listed in
+// tokens below; anything else (e.g. month or weekday names) is matched
+// literally.
+func layoutToPattern(layout string) string {
+	type token struct {
+		literal string
+		pattern string
+	}
+	// Longer/more specific tokens are listed before the shorter tokens they
+	// contain (e.g. "2006" before "06", ".999999999" before ".999").
+	tokens := []token{
+		{"2006", `\d{4}`},
+		{".999999999", `(\.\d+)?`},
+		{".999999", `(\.\d+)?`},
+		{".999", `(\.\d+)?`},
+		{"Z07:00", `(Z|[+-]\d{2}:\d{2})`},
+		{"-07:00", `[+-]\d{2}:\d{2}`},
+		{"06", `\d{2}`},
+		{"01", `\d{2}`},
+		{"02", `\d{2}`},
+		{"15", `\d{2}`},
+		{"03", `\d{2}`},
+		{"04", `\d{2}`},
+		{"05", `\d{2}`},
+		{"%Y", `\d{4}`},
+		{"%y", `\d{2}`},
+		{"%m", `\d{2}`},
+		{"%d", `\d{2}`},
+		{"%H", `\d{2}`},
+		{"%M", `\d{2}`},
+		{"%S", `\d{2}`},
+		{"%z", `[+-]\d{4}`},
+		{"%Z", `[A-Za-z]+`},
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(layout); {
+		matched := false
+		for _, tok := range tokens {
+			if strings.HasPrefix(layout[i:], tok.literal) {
+				out.WriteString(tok.pattern)
+				i += len(tok.literal)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			out.WriteString(regexp.QuoteMeta(string(layout[i])))
+			i++
+		}
+	}
+	return out.String()
+}
+
+// unixRange is the inclusive window of integer values - expressed in the
+// unit of a single Precision - that are accepted as a UNIX timestamp.
 type unixRange struct {
-	Lower int64 // inclusive
-	Upper int64 // inclusive
+	Precision precision
+	Lower     int64 // inclusive
+	Upper     int64 // inclusive
 }
 
 func (u unixRange) LowerString() string { return strconv.FormatInt(u.Lower, 10) }
@@ -298,16 +731,18 @@ func (u unixRange) Contains(i int64) bool {
 	return i >= u.Lower && i <= u.Upper
 }
 
-type matcher struct {
+// rangeMatcher matches byte slices against a single unixRange, quickly
+// disregarding byte slices that it's sure can't fall in the range.
+type rangeMatcher struct {
 	uRange unixRange
 	maxLen int
 	minLen int
 	prefix []byte
 }
 
-func newMatcher(r unixRange) matcher {
+func newRangeMatcher(r unixRange) rangeMatcher {
 	lowerString, upperString := r.LowerString(), r.UpperString()
-	return matcher{
+	return rangeMatcher{
 		uRange: r,
 		maxLen: max(len(lowerString), len(upperString)),
 		minLen: min(len(lowerString), len(upperString)),
@@ -329,16 +764,16 @@ func max(a, b int) int {
 	return b
 }
 
-// Match converts a series of bytes to UNIX timestamp. It quickly disregards
-// byte slices that it's sure can't be a UNIX timestamp.
-func (u matcher) Match(b []byte) (conversion int64, match bool) {
-	if length := len(b); length < u.minLen || length > u.maxLen {
+// Match converts the integer portion of a number to a UNIX timestamp. It
+// quickly disregards byte slices that it's sure can't be a UNIX timestamp.
+func (u rangeMatcher) Match(number []byte) (conversion int64, match bool) {
+	if length := len(number); length < u.minLen || length > u.maxLen {
 		return 0, false
 	}
-	if !bytes.HasPrefix(b, u.prefix) {
+	if !bytes.HasPrefix(number, u.prefix) {
 		return 0, false
 	}
-	conversion, err := strconv.ParseInt(string(b), 10, 64)
+	conversion, err := strconv.ParseInt(string(number), 10, 64)
 	if err != nil {
 		return 0, false
 	}
@@ -362,3 +797,67 @@ func buildPrefixOrdered(shorter, longer []byte) []byte {
 	}
 	return res
 }
+
+// matcher tries the integer portion of a number against every unixRange in
+// turn (there is more than one only when CLI.AutoPrecision is set) and
+// converts the first one that accepts it into a time.Time, taking the
+// optional fractional part into account when CLI.Fractional is set.
+type matcher struct {
+	matchers  []rangeMatcher
+	converter func(unix int64, p precision) time.Time
+}
+
+func newMatcher(ranges unixRanges, converter func(unix int64, p precision) time.Time) matcher {
+	matchers := make([]rangeMatcher, len(ranges))
+	for i, r := range ranges {
+		matchers[i] = newRangeMatcher(r)
+	}
+	return matcher{matchers: matchers, converter: converter}
+}
+
+// Match converts number (and, in --fractional mode, the accompanying
+// fractional part, dot included) to a time.Time if it falls within one of
+// the configured unixRanges.
+func (m matcher) Match(number []byte, frac []byte) (t time.Time, match bool) {
+	for _, rm := range m.matchers {
+		conversion, ok := rm.Match(number)
+		if !ok {
+			continue
+		}
+		if CLI.Fractional && len(frac) > 0 && rm.uRange.Precision == precisionSeconds {
+			nsec, ok := fracNanos(frac)
+			if !ok {
+				continue
+			}
+			return time.Unix(conversion, nsec), true
+		}
+		return m.converter(conversion, rm.uRange.Precision), true
+	}
+	return time.Time{}, false
+}
+
+// fracNanos converts a captured fractional group like ".123456" (dot
+// included) into nanoseconds. It works on the digits directly rather than
+// going through strconv.ParseFloat, since float64's ~15-17 significant
+// digits of precision would otherwise silently round away trailing digits
+// once combined with the integer seconds part.
+func fracNanos(frac []byte) (int64, bool) {
+	const nanoDigits = 9
+	digits := frac[1:] // drop the leading '.'
+	switch {
+	case len(digits) > nanoDigits:
+		digits = digits[:nanoDigits]
+	case len(digits) < nanoDigits:
+		padded := make([]byte, nanoDigits)
+		copy(padded, digits)
+		for i := len(digits); i < nanoDigits; i++ {
+			padded[i] = '0'
+		}
+		digits = padded
+	}
+	nsec, err := strconv.ParseInt(string(digits), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return nsec, true
+}